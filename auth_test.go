@@ -0,0 +1,80 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func runSharedSecretAuth(t *testing.T, secret, signature string, body []byte) *httptest.ResponseRecorder {
+	t.Helper()
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/kong-log", sharedSecretAuth(secret), func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"status": "ok"})
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/kong-log", strings.NewReader(string(body)))
+	if signature != "" {
+		req.Header.Set("X-Kong-Signature", signature)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestSharedSecretAuthAcceptsValidSignature(t *testing.T) {
+	body := []byte(`{"hello":"world"}`)
+	secret := "s3cret"
+	sig := "sha256=" + hmacHex(secret, body)
+
+	w := runSharedSecretAuth(t, secret, sig, body)
+	if w.Code != http.StatusOK {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusOK)
+	}
+}
+
+func TestSharedSecretAuthRejectsBadSignature(t *testing.T) {
+	body := []byte(`{"hello":"world"}`)
+	w := runSharedSecretAuth(t, "s3cret", "sha256=deadbeef", body)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestSharedSecretAuthRejectsMissingSignature(t *testing.T) {
+	body := []byte(`{"hello":"world"}`)
+	w := runSharedSecretAuth(t, "s3cret", "", body)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestValidateTLSFlags(t *testing.T) {
+	tests := []struct {
+		name                string
+		cert, key, clientCA string
+		wantErr             bool
+	}{
+		{"all unset", "", "", "", false},
+		{"cert and key set", "cert.pem", "key.pem", "", false},
+		{"full mTLS set", "cert.pem", "key.pem", "ca.pem", false},
+		{"cert without key", "cert.pem", "", "", true},
+		{"key without cert", "", "key.pem", "", true},
+		{"client-ca without cert/key", "", "", "ca.pem", true},
+		{"client-ca with only cert", "cert.pem", "", "ca.pem", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateTLSFlags(tt.cert, tt.key, tt.clientCA)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateTLSFlags(%q, %q, %q) err = %v, wantErr %v", tt.cert, tt.key, tt.clientCA, err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	WhenSecond = iota
+	WhenMinute
+	WhenHour
+	WhenDay
+)
+
+// RotatingFileHandler rotates the open log file whenever the configured
+// time interval elapses or the file grows past MaxSizeMB, whichever comes
+// first. Closed segments are optionally gzip'd in the background, and
+// MaxBackups / MaxAgeDays are enforced by pruning the oldest rotated files
+// matching baseName in path.Dir(baseName). This is the same policy popularised
+// by lumberjack-style rolling loggers.
+type RotatingFileHandler struct {
+	fd *os.File
+
+	baseName   string
+	interval   int64
+	suffix     string
+	rolloverAt int64
+
+	maxSizeBytes int64
+	written      int64
+
+	maxBackups int
+	maxAgeDays int
+	compress   bool
+
+	mux sync.Mutex
+}
+
+func newLogHandler(baseName string, when int8, interval int, maxSizeMB, maxBackups, maxAgeDays int, compress bool) (*RotatingFileHandler, error) {
+	dir := path.Dir(baseName)
+	os.MkdirAll(dir, 0777)
+
+	h := new(RotatingFileHandler)
+
+	h.baseName = baseName
+	h.maxSizeBytes = int64(maxSizeMB) * 1024 * 1024
+	h.maxBackups = maxBackups
+	h.maxAgeDays = maxAgeDays
+	h.compress = compress
+
+	switch when {
+	case WhenSecond:
+		h.interval = 1
+		h.suffix = "2006-01-02_15-04-05"
+	case WhenMinute:
+		h.interval = 60
+		h.suffix = "2006-01-02_15-04"
+	case WhenHour:
+		h.interval = 3600
+		h.suffix = "2006-01-02_15"
+	case WhenDay:
+		h.interval = 3600 * 24
+		h.suffix = "2006-01-02"
+	default:
+		return nil, fmt.Errorf("invalid when_rotate: %d", when)
+	}
+
+	h.interval = h.interval * int64(interval)
+
+	var err error
+	h.fd, err = os.OpenFile(h.baseName, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+	if err != nil {
+		return nil, err
+	}
+
+	fInfo, _ := h.fd.Stat()
+	h.rolloverAt = fInfo.ModTime().Unix() + h.interval
+	h.written = fInfo.Size()
+
+	return h, nil
+}
+
+func (l *RotatingFileHandler) shouldRollover(now time.Time) bool {
+	if l.rolloverAt <= now.Unix() {
+		return true
+	}
+	if l.maxSizeBytes > 0 && l.written >= l.maxSizeBytes {
+		return true
+	}
+	return false
+}
+
+func (l *RotatingFileHandler) doRollover() {
+	now := time.Now()
+
+	if !l.shouldRollover(now) {
+		return
+	}
+
+	fName := l.rotatedName(now)
+	l.fd.Close()
+	e := os.Rename(l.baseName, fName)
+	if e != nil {
+		panic(e)
+	}
+	rotationEvents.Inc()
+
+	if l.compress {
+		go compressAndRemove(fName)
+	}
+
+	l.fd, _ = os.OpenFile(l.baseName, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+
+	l.rolloverAt = time.Now().Unix() + l.interval
+	l.written = 0
+
+	l.prune()
+}
+
+// rotatedName builds the name a segment rolled over at now gets, as
+// baseName + "." + formatted timestamp. Size-based rotation can fire more
+// than once inside the same time bucket, so a numeric suffix is appended
+// until the name doesn't collide with an existing file, rather than letting
+// os.Rename silently clobber the previous segment.
+func (l *RotatingFileHandler) rotatedName(now time.Time) string {
+	base := l.baseName + "." + now.Format(l.suffix)
+	name := base
+	for i := 1; ; i++ {
+		if _, err := os.Stat(name); os.IsNotExist(err) {
+			return name
+		}
+		name = fmt.Sprintf("%s.%d", base, i)
+	}
+}
+
+// compressAndRemove gzips fName to fName+".gz" and removes the uncompressed
+// segment once the copy succeeds. Runs in its own goroutine so it never
+// blocks doRollover.
+func compressAndRemove(fName string) {
+	src, err := os.Open(fName)
+	if err != nil {
+		return
+	}
+	defer src.Close()
+
+	dst, err := os.Create(fName + ".gz")
+	if err != nil {
+		return
+	}
+	gw := gzip.NewWriter(dst)
+
+	if _, err := io.Copy(gw, src); err != nil {
+		gw.Close()
+		dst.Close()
+		return
+	}
+	gw.Close()
+	dst.Close()
+
+	os.Remove(fName)
+}
+
+// prune enforces MaxBackups and MaxAgeDays against the rotated files that
+// match baseName in path.Dir(baseName), oldest first.
+func (l *RotatingFileHandler) prune() {
+	if l.maxBackups <= 0 && l.maxAgeDays <= 0 {
+		return
+	}
+
+	matches, err := filepath.Glob(l.baseName + ".*")
+	if err != nil {
+		return
+	}
+
+	infos := make([]os.FileInfo, 0, len(matches))
+	for _, m := range matches {
+		fi, err := os.Stat(m)
+		if err != nil {
+			continue
+		}
+		infos = append(infos, fi)
+	}
+
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].ModTime().Before(infos[j].ModTime())
+	})
+
+	if l.maxAgeDays > 0 {
+		cutoff := time.Now().AddDate(0, 0, -l.maxAgeDays)
+		kept := infos[:0]
+		for _, fi := range infos {
+			if fi.ModTime().Before(cutoff) {
+				os.Remove(filepath.Join(path.Dir(l.baseName), fi.Name()))
+				continue
+			}
+			kept = append(kept, fi)
+		}
+		infos = kept
+	}
+
+	if l.maxBackups > 0 && len(infos) > l.maxBackups {
+		toRemove := infos[:len(infos)-l.maxBackups]
+		for _, fi := range toRemove {
+			os.Remove(filepath.Join(path.Dir(l.baseName), fi.Name()))
+		}
+	}
+}
+
+func (l *RotatingFileHandler) Write(p []byte) (n int, err error) {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	l.doRollover()
+	n, err = fmt.Fprintln(l.fd, string(p))
+	l.written += int64(n)
+	return n, err
+}
+
+func (l *RotatingFileHandler) Close() error {
+	return l.fd.Close()
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRotatedNameDeduplicatesSameBucketRotations(t *testing.T) {
+	dir := t.TempDir()
+	base := filepath.Join(dir, "kong.log")
+	h := &RotatingFileHandler{baseName: base, suffix: "2006-01-02_15"}
+
+	now := time.Date(2026, 7, 26, 10, 0, 0, 0, time.UTC)
+	first := h.rotatedName(now)
+	if filepath.Ext(first) == "" {
+		t.Fatalf("rotatedName() = %q, expected a dotted timestamp suffix", first)
+	}
+	if err := os.WriteFile(first, []byte("x"), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	second := h.rotatedName(now)
+	if second == first {
+		t.Fatalf("rotatedName() returned %q twice for the same bucket; rotation would clobber the first file", first)
+	}
+	if _, err := os.Stat(second); !os.IsNotExist(err) {
+		t.Fatalf("rotatedName() returned an existing path %q", second)
+	}
+}
+
+func TestPruneEnforcesMaxBackups(t *testing.T) {
+	dir := t.TempDir()
+	base := filepath.Join(dir, "kong.log")
+
+	names := []string{base + ".a", base + ".b", base + ".c"}
+	for i, n := range names {
+		if err := os.WriteFile(n, []byte("x"), 0644); err != nil {
+			t.Fatalf("WriteFile: %v", err)
+		}
+		modTime := time.Now().Add(time.Duration(i) * time.Minute)
+		if err := os.Chtimes(n, modTime, modTime); err != nil {
+			t.Fatalf("Chtimes: %v", err)
+		}
+	}
+
+	h := &RotatingFileHandler{baseName: base, maxBackups: 1}
+	h.prune()
+
+	remaining, err := filepath.Glob(base + ".*")
+	if err != nil {
+		t.Fatalf("Glob: %v", err)
+	}
+	if len(remaining) != 1 {
+		t.Fatalf("remaining = %v, want exactly the newest rotated file", remaining)
+	}
+	if remaining[0] != names[len(names)-1] {
+		t.Errorf("kept %q, want the most recently modified file %q", remaining[0], names[len(names)-1])
+	}
+}
+
+func TestPruneEnforcesMaxAgeDays(t *testing.T) {
+	dir := t.TempDir()
+	base := filepath.Join(dir, "kong.log")
+
+	oldFile := base + ".old"
+	newFile := base + ".new"
+	for _, n := range []string{oldFile, newFile} {
+		if err := os.WriteFile(n, []byte("x"), 0644); err != nil {
+			t.Fatalf("WriteFile: %v", err)
+		}
+	}
+	oldTime := time.Now().AddDate(0, 0, -10)
+	if err := os.Chtimes(oldFile, oldTime, oldTime); err != nil {
+		t.Fatalf("Chtimes: %v", err)
+	}
+
+	h := &RotatingFileHandler{baseName: base, maxAgeDays: 5}
+	h.prune()
+
+	if _, err := os.Stat(oldFile); !os.IsNotExist(err) {
+		t.Errorf("expected %q to be pruned for exceeding max age", oldFile)
+	}
+	if _, err := os.Stat(newFile); err != nil {
+		t.Errorf("expected %q to survive pruning, got err: %v", newFile, err)
+	}
+}
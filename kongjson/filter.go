@@ -0,0 +1,103 @@
+package kongjson
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Filter drops a record when it matches a configured expression. We keep the
+// expression language deliberately small rather than pulling in a full CEL
+// evaluator: a comma-separated list of `field op value` clauses, ANDed
+// together, against the handful of fields operators actually filter on.
+type Filter struct {
+	clauses []clause
+}
+
+type clause struct {
+	field string
+	op    string
+	value string
+}
+
+// FilterConfig is the YAML shape for a drop expression, e.g.
+// `expression: "response.status == 200, consumer.id == abc123"`.
+type FilterConfig struct {
+	Expression string `yaml:"expression"`
+}
+
+func NewFilter(cfg FilterConfig) (*Filter, error) {
+	if strings.TrimSpace(cfg.Expression) == "" {
+		return &Filter{}, nil
+	}
+
+	f := &Filter{}
+	for _, part := range strings.Split(cfg.Expression, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		c, err := parseClause(part)
+		if err != nil {
+			return nil, err
+		}
+		f.clauses = append(f.clauses, c)
+	}
+	return f, nil
+}
+
+func parseClause(s string) (clause, error) {
+	for _, op := range []string{"!=", "=="} {
+		if idx := strings.Index(s, op); idx >= 0 {
+			return clause{
+				field: strings.TrimSpace(s[:idx]),
+				op:    op,
+				value: strings.Trim(strings.TrimSpace(s[idx+len(op):]), `"'`),
+			}, nil
+		}
+	}
+	return clause{}, fmt.Errorf("kongjson: unparseable filter clause %q", s)
+}
+
+// Drop reports whether r matches every configured clause and should
+// therefore be dropped. A Filter with no clauses never drops.
+func (f *Filter) Drop(r *Record) bool {
+	if len(f.clauses) == 0 {
+		return false
+	}
+	for _, c := range f.clauses {
+		if !c.matches(r) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c clause) matches(r *Record) bool {
+	actual := fieldValue(r, c.field)
+	switch c.op {
+	case "==":
+		return actual == c.value
+	case "!=":
+		return actual != c.value
+	default:
+		return false
+	}
+}
+
+func fieldValue(r *Record, field string) string {
+	switch field {
+	case "response.status":
+		return strconv.Itoa(r.Response.Status)
+	case "route.id":
+		return r.Route.ID
+	case "service.id":
+		return r.Service.ID
+	case "consumer.id":
+		return r.Consumer.ID
+	case "request.method":
+		return r.Request.Method
+	default:
+		return ""
+	}
+}
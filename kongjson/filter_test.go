@@ -0,0 +1,67 @@
+package kongjson
+
+import "testing"
+
+func TestFilterDrop(t *testing.T) {
+	tests := []struct {
+		name       string
+		expression string
+		record     *Record
+		want       bool
+	}{
+		{
+			name:       "empty expression never drops",
+			expression: "",
+			record:     &Record{Response: Response{Status: 500}},
+			want:       false,
+		},
+		{
+			name:       "single clause match drops",
+			expression: `response.status == 200`,
+			record:     &Record{Response: Response{Status: 200}},
+			want:       true,
+		},
+		{
+			name:       "single clause mismatch keeps",
+			expression: `response.status == 200`,
+			record:     &Record{Response: Response{Status: 404}},
+			want:       false,
+		},
+		{
+			name:       "ANDed clauses require all to match",
+			expression: `response.status == 200, consumer.id == abc123`,
+			record:     &Record{Response: Response{Status: 200}, Consumer: Entity{ID: "other"}},
+			want:       false,
+		},
+		{
+			name:       "ANDed clauses all match",
+			expression: `response.status == 200, consumer.id == abc123`,
+			record:     &Record{Response: Response{Status: 200}, Consumer: Entity{ID: "abc123"}},
+			want:       true,
+		},
+		{
+			name:       "!= operator",
+			expression: `request.method != GET`,
+			record:     &Record{Request: Request{Method: "POST"}},
+			want:       true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := NewFilter(FilterConfig{Expression: tt.expression})
+			if err != nil {
+				t.Fatalf("NewFilter: %v", err)
+			}
+			if got := f.Drop(tt.record); got != tt.want {
+				t.Errorf("Drop() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewFilterRejectsUnparseableClause(t *testing.T) {
+	if _, err := NewFilter(FilterConfig{Expression: "response.status"}); err == nil {
+		t.Fatal("expected error for clause with no operator, got nil")
+	}
+}
@@ -0,0 +1,143 @@
+// Package kongjson decodes the JSON document emitted by Kong's http-log
+// plugin into a typed Record and runs it through a configurable
+// sampling/filtering/redaction/enrichment pipeline before it reaches a sink.
+package kongjson
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// Record mirrors the subset of Kong's http-log plugin payload the pipeline
+// needs to act on. Unknown fields are preserved in raw so re-marshalling
+// never drops data the pipeline doesn't understand.
+type Record struct {
+	Request   Request   `json:"request"`
+	Response  Response  `json:"response"`
+	Route     Entity    `json:"route"`
+	Service   Entity    `json:"service"`
+	Consumer  Entity    `json:"consumer"`
+	Latencies Latencies `json:"latencies"`
+	ClientIP  string    `json:"client_ip"`
+	StartedAt int64     `json:"started_at"`
+
+	// Enrichment adds fields here that Kong itself never sends.
+	Hostname   string `json:"hostname,omitempty"`
+	ReceivedAt int64  `json:"received_at,omitempty"`
+
+	raw map[string]json.RawMessage `json:"-"`
+}
+
+type Request struct {
+	Method      string              `json:"method"`
+	URI         string              `json:"uri"`
+	Size        int                 `json:"size"`
+	Headers     map[string]string   `json:"headers"`
+	QueryString map[string][]string `json:"querystring"`
+}
+
+type Response struct {
+	Status  int               `json:"status"`
+	Size    int               `json:"size"`
+	Headers map[string]string `json:"headers"`
+}
+
+type Entity struct {
+	ID   string `json:"id"`
+	Name string `json:"name,omitempty"`
+}
+
+type Latencies struct {
+	Kong    int64 `json:"kong"`
+	Proxy   int64 `json:"proxy"`
+	Request int64 `json:"request"`
+}
+
+// Decode parses a raw Kong log payload, keeping unrecognised top-level
+// fields so Encode can reproduce them.
+func Decode(p []byte) (*Record, error) {
+	r := &Record{}
+	if err := json.Unmarshal(p, r); err != nil {
+		return nil, err
+	}
+
+	raw := map[string]json.RawMessage{}
+	if err := json.Unmarshal(p, &raw); err != nil {
+		return nil, err
+	}
+	r.raw = raw
+
+	return r, nil
+}
+
+// Encode re-serialises the record, starting from the original payload so
+// fields the pipeline never touched round-trip unchanged. Known fields are
+// merged in recursively, object by object, rather than replacing whole
+// top-level keys, so unmodeled nested fields (route.paths, service.retries,
+// consumer.username, ...) survive alongside the ones the pipeline edited.
+func (r *Record) Encode() ([]byte, error) {
+	known, err := json.Marshal(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var overlay map[string]json.RawMessage
+	if err := json.Unmarshal(known, &overlay); err != nil {
+		return nil, err
+	}
+
+	merged := map[string]json.RawMessage{}
+	for k, v := range r.raw {
+		merged[k] = v
+	}
+	for k, v := range overlay {
+		if base, ok := merged[k]; ok {
+			merged[k] = mergeRaw(base, v)
+			continue
+		}
+		merged[k] = v
+	}
+
+	return json.Marshal(merged)
+}
+
+// mergeRaw deep-merges two JSON values. When both are objects, their keys
+// are merged recursively with overlay winning on conflicts; otherwise
+// overlay replaces base wholesale (e.g. when a field changed type, or isn't
+// an object at all).
+func mergeRaw(base, overlay json.RawMessage) json.RawMessage {
+	if !isJSONObject(base) || !isJSONObject(overlay) {
+		return overlay
+	}
+
+	var baseMap, overlayMap map[string]json.RawMessage
+	if err := json.Unmarshal(base, &baseMap); err != nil {
+		return overlay
+	}
+	if err := json.Unmarshal(overlay, &overlayMap); err != nil {
+		return overlay
+	}
+
+	merged := map[string]json.RawMessage{}
+	for k, v := range baseMap {
+		merged[k] = v
+	}
+	for k, v := range overlayMap {
+		if existing, ok := merged[k]; ok {
+			merged[k] = mergeRaw(existing, v)
+			continue
+		}
+		merged[k] = v
+	}
+
+	out, err := json.Marshal(merged)
+	if err != nil {
+		return overlay
+	}
+	return out
+}
+
+func isJSONObject(v json.RawMessage) bool {
+	trimmed := bytes.TrimSpace(v)
+	return len(trimmed) > 0 && trimmed[0] == '{'
+}
@@ -0,0 +1,131 @@
+package kongjson
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestDecodeEncodeRoundTrip(t *testing.T) {
+	in := []byte(`{
+		"request": {"method": "GET", "uri": "/foo", "size": 10, "headers": {}, "querystring": {}},
+		"response": {"status": 200, "size": 20, "headers": {}},
+		"route": {"id": "r1", "paths": ["/foo"], "protocols": ["http", "https"]},
+		"service": {"id": "s1", "retries": 5},
+		"consumer": {"id": "c1", "username": "alice"},
+		"latencies": {"kong": 1, "proxy": 2, "request": 3},
+		"client_ip": "127.0.0.1",
+		"started_at": 1000
+	}`)
+
+	r, err := Decode(in)
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if r.Route.ID != "r1" || r.Service.ID != "s1" || r.Consumer.ID != "c1" {
+		t.Fatalf("unexpected decoded entities: %+v %+v %+v", r.Route, r.Service, r.Consumer)
+	}
+
+	out, err := r.Encode()
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+
+	var got map[string]interface{}
+	if err := json.Unmarshal(out, &got); err != nil {
+		t.Fatalf("unmarshal encoded output: %v", err)
+	}
+
+	route, ok := got["route"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("route missing or not an object: %v", got["route"])
+	}
+	if route["id"] != "r1" {
+		t.Errorf("route.id = %v, want r1", route["id"])
+	}
+	if _, ok := route["paths"]; !ok {
+		t.Errorf("route.paths dropped, got %v", route)
+	}
+	if _, ok := route["protocols"]; !ok {
+		t.Errorf("route.protocols dropped, got %v", route)
+	}
+
+	service, ok := got["service"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("service missing or not an object: %v", got["service"])
+	}
+	if _, ok := service["retries"]; !ok {
+		t.Errorf("service.retries dropped, got %v", service)
+	}
+
+	consumer, ok := got["consumer"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("consumer missing or not an object: %v", got["consumer"])
+	}
+	if _, ok := consumer["username"]; !ok {
+		t.Errorf("consumer.username dropped, got %v", consumer)
+	}
+}
+
+func TestEncodePreservesUnknownTopLevelFields(t *testing.T) {
+	in := []byte(`{
+		"request": {"method": "GET", "uri": "/", "size": 0, "headers": {}, "querystring": {}},
+		"response": {"status": 200, "size": 0, "headers": {}},
+		"route": {"id": "r1"},
+		"service": {"id": "s1"},
+		"consumer": {"id": "c1"},
+		"latencies": {"kong": 0, "proxy": 0, "request": 0},
+		"client_ip": "127.0.0.1",
+		"started_at": 1,
+		"tries": [{"balancer_latency": 1}]
+	}`)
+
+	r, err := Decode(in)
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+
+	out, err := r.Encode()
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+
+	var got map[string]json.RawMessage
+	if err := json.Unmarshal(out, &got); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if _, ok := got["tries"]; !ok {
+		t.Errorf("unknown top-level field %q dropped", "tries")
+	}
+}
+
+func TestEncodeReflectsEnrichment(t *testing.T) {
+	in := []byte(`{
+		"request": {"method": "GET", "uri": "/", "size": 0, "headers": {}, "querystring": {}},
+		"response": {"status": 200, "size": 0, "headers": {}},
+		"route": {"id": "r1"},
+		"service": {"id": "s1"},
+		"consumer": {"id": "c1"},
+		"latencies": {"kong": 0, "proxy": 0, "request": 0},
+		"client_ip": "127.0.0.1",
+		"started_at": 1
+	}`)
+
+	r, err := Decode(in)
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	r.Hostname = "worker-1"
+
+	out, err := r.Encode()
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+
+	var got map[string]interface{}
+	if err := json.Unmarshal(out, &got); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if got["hostname"] != "worker-1" {
+		t.Errorf("hostname = %v, want worker-1", got["hostname"])
+	}
+}
@@ -0,0 +1,67 @@
+package kongjson
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"strings"
+	"time"
+)
+
+// RedactConfig lists the header and query-parameter names to strip or hash
+// before a record is persisted.
+type RedactConfig struct {
+	Mode        string   `yaml:"mode"` // "strip" (default) or "hash"
+	Headers     []string `yaml:"headers"`
+	QueryParams []string `yaml:"query_params"`
+}
+
+// Redact applies cfg to r in place.
+func Redact(r *Record, cfg RedactConfig) {
+	hash := strings.EqualFold(cfg.Mode, "hash")
+
+	for _, h := range cfg.Headers {
+		h = strings.ToLower(h)
+		redactMap(r.Request.Headers, h, hash)
+		redactMap(r.Response.Headers, h, hash)
+	}
+
+	for _, qp := range cfg.QueryParams {
+		if vals, ok := r.Request.QueryString[qp]; ok {
+			for i, v := range vals {
+				if hash {
+					vals[i] = hashValue(v)
+				} else {
+					vals[i] = ""
+				}
+			}
+			r.Request.QueryString[qp] = vals
+		}
+	}
+}
+
+func redactMap(headers map[string]string, key string, hash bool) {
+	for k := range headers {
+		if strings.ToLower(k) != key {
+			continue
+		}
+		if hash {
+			headers[k] = hashValue(headers[k])
+		} else {
+			headers[k] = ""
+		}
+	}
+}
+
+func hashValue(v string) string {
+	sum := sha256.Sum256([]byte(v))
+	return hex.EncodeToString(sum[:])
+}
+
+// Enrich stamps r with the fields Kong itself never sends: the host this
+// receiver is running on, and the time the record was received.
+func Enrich(r *Record, now time.Time) {
+	host, _ := os.Hostname()
+	r.Hostname = host
+	r.ReceivedAt = now.Unix()
+}
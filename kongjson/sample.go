@@ -0,0 +1,92 @@
+package kongjson
+
+import (
+	"math/rand"
+	"sync"
+)
+
+// Sampler decides whether a decoded record should continue through the
+// pipeline. Rate, NonSuccess and per-route reservoir sampling below cover
+// the three modes callers configure via SamplingConfig.
+type Sampler interface {
+	Keep(r *Record) bool
+}
+
+// SamplingConfig selects and configures one Sampler. Mode is one of
+// "", "rate", "non-success", "reservoir".
+type SamplingConfig struct {
+	Mode          string `yaml:"mode"`
+	RateOneInN    int    `yaml:"rate_one_in_n"`
+	ReservoirSize int    `yaml:"reservoir_size"`
+}
+
+func NewSampler(cfg SamplingConfig) Sampler {
+	switch cfg.Mode {
+	case "rate":
+		n := cfg.RateOneInN
+		if n <= 0 {
+			n = 1
+		}
+		return &rateSampler{n: n}
+	case "non-success":
+		return nonSuccessSampler{}
+	case "reservoir":
+		size := cfg.ReservoirSize
+		if size <= 0 {
+			size = 1
+		}
+		return &reservoirSampler{size: size, seen: map[string]int{}}
+	default:
+		return keepAllSampler{}
+	}
+}
+
+type keepAllSampler struct{}
+
+func (keepAllSampler) Keep(r *Record) bool { return true }
+
+// rateSampler keeps 1 record in every n, counted process-wide.
+type rateSampler struct {
+	n     int
+	mux   sync.Mutex
+	count int
+}
+
+func (s *rateSampler) Keep(r *Record) bool {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.count++
+	return s.count%s.n == 0
+}
+
+// nonSuccessSampler keeps every record whose response status is not 2xx.
+type nonSuccessSampler struct{}
+
+func (nonSuccessSampler) Keep(r *Record) bool {
+	return r.Response.Status < 200 || r.Response.Status >= 300
+}
+
+// reservoirSampler keeps, per route, the first `size` records, then keeps
+// the nth record seen for that route with the classic reservoir inclusion
+// probability (size/n) rather than retaining and replaying a buffered window.
+// Kept records are never evicted, so the number kept per route keeps growing,
+// roughly as size*(1+ln(n/size)).
+type reservoirSampler struct {
+	size int
+	mux  sync.Mutex
+	seen map[string]int
+}
+
+func (s *reservoirSampler) Keep(r *Record) bool {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	routeID := r.Route.ID
+	s.seen[routeID]++
+	n := s.seen[routeID]
+
+	if n <= s.size {
+		return true
+	}
+	return rand.Intn(n) < s.size
+}
@@ -0,0 +1,63 @@
+package kongjson
+
+import "testing"
+
+func TestRateSamplerKeepsOneInN(t *testing.T) {
+	s := NewSampler(SamplingConfig{Mode: "rate", RateOneInN: 3})
+
+	var kept int
+	for i := 0; i < 9; i++ {
+		if s.Keep(&Record{}) {
+			kept++
+		}
+	}
+	if kept != 3 {
+		t.Errorf("kept = %d, want 3", kept)
+	}
+}
+
+func TestNonSuccessSamplerKeepsOnlyNon2xx(t *testing.T) {
+	s := NewSampler(SamplingConfig{Mode: "non-success"})
+
+	cases := []struct {
+		status int
+		want   bool
+	}{
+		{200, false},
+		{204, false},
+		{299, false},
+		{404, true},
+		{500, true},
+		{100, true},
+	}
+	for _, c := range cases {
+		got := s.Keep(&Record{Response: Response{Status: c.status}})
+		if got != c.want {
+			t.Errorf("status %d: Keep() = %v, want %v", c.status, got, c.want)
+		}
+	}
+}
+
+func TestReservoirSamplerAlwaysKeepsFirstSizePerRoute(t *testing.T) {
+	s := NewSampler(SamplingConfig{Mode: "reservoir", ReservoirSize: 2})
+
+	for i := 0; i < 2; i++ {
+		if !s.Keep(&Record{Route: Entity{ID: "r1"}}) {
+			t.Fatalf("expected first %d records for a route to always be kept", 2)
+		}
+	}
+
+	// A different route's reservoir is tracked independently.
+	if !s.Keep(&Record{Route: Entity{ID: "r2"}}) {
+		t.Error("expected first record of a new route to be kept")
+	}
+}
+
+func TestDefaultSamplerKeepsEverything(t *testing.T) {
+	s := NewSampler(SamplingConfig{})
+	for i := 0; i < 5; i++ {
+		if !s.Keep(&Record{}) {
+			t.Fatal("default sampler should keep every record")
+		}
+	}
+}
@@ -1,118 +1,77 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
-	"github.com/gin-gonic/gin"
 	"net/http"
 	"os"
-	"path"
+	"os/signal"
 	"sync"
+	"syscall"
 	"time"
-)
-
-type Handler interface {
-	Write(p []byte) (n int, err error)
-	Close() error
-}
 
-type TimeRotatingFileHandler struct {
-	fd *os.File
-
-	baseName   string
-	interval   int64
-	suffix     string
-	rolloverAt int64
-	mux sync.Mutex
-}
-
-const (
-	WhenSecond = iota
-	WhenMinute
-	WhenHour
-	WhenDay
+	"github.com/gin-gonic/gin"
+	"github.com/jamesBan/kong-http-log/kongjson"
 )
 
-func newLogHandler(baseName string, when int8, interval int) (*TimeRotatingFileHandler, error) {
-	dir := path.Dir(baseName)
-	os.MkdirAll(dir, 0777)
-
-	h := new(TimeRotatingFileHandler)
-
-	h.baseName = baseName
-
-	switch when {
-	case WhenSecond:
-		h.interval = 1
-		h.suffix = "2006-01-02_15-04-05"
-	case WhenMinute:
-		h.interval = 60
-		h.suffix = "2006-01-02_15-04"
-	case WhenHour:
-		h.interval = 3600
-		h.suffix = "2006-01-02_15"
-	case WhenDay:
-		h.interval = 3600 * 24
-		h.suffix = "2006-01-02"
-	default:
-		return nil, fmt.Errorf("invalid when_rotate: %d", when)
-	}
-
-	h.interval = h.interval * int64(interval)
-
-	var err error
-	h.fd, err = os.OpenFile(h.baseName, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
-	if err != nil {
-		return nil, err
-	}
-
-	fInfo, _ := h.fd.Stat()
-	h.rolloverAt = fInfo.ModTime().Unix() + h.interval
+type ConfigStruct struct {
+	logPath    string
+	serverIp   string
+	serverPort string
+	handledNum uint64
+	startTime  time.Time
 
-	return h, nil
-}
+	maxSizeMB  int
+	maxBackups int
+	maxAgeDays int
+	compress   bool
 
-func (l *TimeRotatingFileHandler) doRollover() {
-	now := time.Now()
+	sinks       string
+	sinksConfig string
 
-	if l.rolloverAt <= now.Unix() {
-		fName := l.baseName + now.Format(l.suffix)
-		l.fd.Close()
-		e := os.Rename(l.baseName, fName)
-		if e != nil {
-			panic(e)
-		}
+	metricsAddr string
 
-		l.fd, _ = os.OpenFile(l.baseName, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+	channelSize     int
+	channelBehavior string
 
-		l.rolloverAt = time.Now().Unix() + l.interval
-	}
-}
+	readHeaderTimeout time.Duration
+	readTimeout       time.Duration
+	writeTimeout      time.Duration
+	idleTimeout       time.Duration
+	shutdownTimeout   time.Duration
 
-func (l *TimeRotatingFileHandler) Write(p []byte) (n int, err error) {
-	l.mux.Lock()
-	l.doRollover()
-	l.mux.Unlock()
-	return fmt.Fprintln(l.fd, string(p))
-}
+	pipelineConfig string
 
-func (l *TimeRotatingFileHandler) Close() (error) {
-	return l.fd.Close()
-}
-
-
-type ConfigStruct struct {
-	logPath    string
-	serverIp   string
-	serverPort string
-	handledNum uint64
-	startTime  time.Time
+	tlsCert      string
+	tlsKey       string
+	clientCA     string
+	sharedSecret string
 }
 
 func (s *ConfigStruct) Setup() {
 	flag.StringVar(&s.logPath, "log-path", "/var/log/kong-log", "log path")
 	flag.StringVar(&s.serverIp, "server-ip", "127.0.0.1", "listen ip")
 	flag.StringVar(&s.serverPort, "server-port", "9513", "listen port")
+	flag.IntVar(&s.maxSizeMB, "max-size-mb", 0, "rotate once the current log file exceeds this size in MB (0 disables size-based rotation)")
+	flag.IntVar(&s.maxBackups, "max-backups", 0, "maximum number of rotated files to keep (0 keeps all)")
+	flag.IntVar(&s.maxAgeDays, "max-age-days", 0, "maximum age in days to keep a rotated file (0 disables age-based pruning)")
+	flag.BoolVar(&s.compress, "compress", false, "gzip rotated log files once closed")
+	flag.StringVar(&s.sinks, "sinks", "", "comma-separated list of sink types to enable (file,kafka,s3,es); defaults to a single file sink")
+	flag.StringVar(&s.sinksConfig, "sinks-config", "", "path to a YAML file describing the sinks to run, overrides -sinks")
+	flag.StringVar(&s.metricsAddr, "metrics-addr", "127.0.0.1:9514", "address to serve /metrics, /healthz and /readyz on")
+	flag.IntVar(&s.channelSize, "channel-size", 1000, "size of the bounded ingest channel between the HTTP handler and the sink workers")
+	flag.StringVar(&s.channelBehavior, "channel-full-behavior", "reject", "what to do when the ingest channel is full: reject (503) or block")
+	flag.DurationVar(&s.readHeaderTimeout, "read-header-timeout", 5*time.Second, "HTTP server ReadHeaderTimeout")
+	flag.DurationVar(&s.readTimeout, "read-timeout", 10*time.Second, "HTTP server ReadTimeout")
+	flag.DurationVar(&s.writeTimeout, "write-timeout", 10*time.Second, "HTTP server WriteTimeout")
+	flag.DurationVar(&s.idleTimeout, "idle-timeout", 60*time.Second, "HTTP server IdleTimeout")
+	flag.DurationVar(&s.shutdownTimeout, "shutdown-timeout", 30*time.Second, "how long to wait for in-flight HTTP requests to finish on shutdown; draining the channel and sinks afterwards is not bounded by it")
+	flag.StringVar(&s.pipelineConfig, "pipeline-config", "", "path to a YAML file configuring sampling/filtering/redaction/enrichment; reloaded on SIGHUP")
+	flag.StringVar(&s.tlsCert, "tls-cert", "", "TLS certificate to terminate the ingest listener with")
+	flag.StringVar(&s.tlsKey, "tls-key", "", "TLS private key matching -tls-cert")
+	flag.StringVar(&s.clientCA, "client-ca", "", "CA bundle to verify Kong's client certificate against (enables mTLS)")
+	flag.StringVar(&s.sharedSecret, "shared-secret", "", "shared HMAC key Kong signs the body with; verified via the X-Kong-Signature header")
 }
 
 
@@ -121,37 +80,81 @@ func main() {
 	c.Setup()
 	flag.Parse()
 
-	handler, err := newLogHandler(c.logPath, WhenHour, 3600)
+	sinks, err := buildSinks(c)
 	if err != nil {
 		panic(err)
 	}
 
-	time.Now()
+	pipeline, err := kongjson.LoadManager(c.pipelineConfig)
+	if err != nil {
+		panic(err)
+	}
 
 	gin.SetMode(gin.ReleaseMode)
 	r := gin.Default()
 
 	workerNum := 2
-	channel := make(chan []byte, workerNum)
-	defer close(channel)
+	channel := make(chan []byte, c.channelSize)
 
+	var workers sync.WaitGroup
+	workers.Add(workerNum)
 	for i := 0; i < workerNum; i++ {
-		go handleLog(channel, handler, c)
+		go handleLog(channel, sinks, pipeline, c, &workers)
 	}
 
-	r.POST("/kong-log", func(c *gin.Context) {
-		data, err1 := c.GetRawData()
-		if err1 != nil {
-			c.JSON(http.StatusOK, gin.H{"status": "ok"})
-			return
+	// inFlight tracks POST handler goroutines between the moment they decide
+	// to send on channel and the moment that send returns, so shutdown can
+	// wait for them before closing channel. Without it, a handler blocked on
+	// channel <- data (channelBehavior "block") could still be holding that
+	// send when close(channel) runs, panicking with "send on closed channel".
+	var inFlight sync.WaitGroup
+
+	metricsServer := startMetricsServer(c.metricsAddr, channel, sinks)
+
+	postHandlers := make([]gin.HandlerFunc, 0, 2)
+	if c.sharedSecret != "" {
+		postHandlers = append(postHandlers, sharedSecretAuth(c.sharedSecret))
+	}
+	postHandlers = append(postHandlers, func(ctx *gin.Context) {
+		start := time.Now()
+		defer func() { handlerLatency.Observe(time.Since(start).Seconds()) }()
+
+		var data []byte
+		if cached, ok := ctx.Get(rawBodyKey); ok {
+			data = cached.([]byte)
+		} else {
+			var err1 error
+			data, err1 = ctx.GetRawData()
+			if err1 != nil {
+				ctx.JSON(http.StatusOK, gin.H{"status": "ok"})
+				return
+			}
+		}
+
+		requestBodySize.Observe(float64(len(data)))
+
+		inFlight.Add(1)
+		if c.channelBehavior == "reject" {
+			select {
+			case channel <- data:
+			default:
+				inFlight.Done()
+				rejectedRequests.Inc()
+				ctx.JSON(http.StatusServiceUnavailable, gin.H{"status": "unavailable"})
+				return
+			}
+		} else {
+			channel <- data
 		}
+		inFlight.Done()
 
-		//send data
-		channel <- data
+		acceptedRequests.Inc()
+		bytesWritten.Add(float64(len(data)))
 
-		c.JSON(http.StatusOK, gin.H{"status": "ok"})
+		ctx.JSON(http.StatusOK, gin.H{"status": "ok"})
 
 	})
+	r.POST("/kong-log", postHandlers...)
 
 	r.GET("/kong-log-stat", func(content *gin.Context) {
 		content.JSON(http.StatusOK, gin.H{
@@ -161,20 +164,86 @@ func main() {
 			"start-time": c.startTime,
 			"duration": time.Now().Sub(c.startTime).String(),
 			"handled": c.handledNum,
+			"sinks": sinks.Stats(),
 		})
 	})
 
+	c.startTime = time.Now()
 
+	if err := validateTLSFlags(c.tlsCert, c.tlsKey, c.clientCA); err != nil {
+		panic(err)
+	}
 
-	c.startTime = time.Now()
-	if err := r.Run(c.serverIp+":"+c.serverPort); err != nil {
+	tlsConfig, err := buildTLSConfig(c.clientCA)
+	if err != nil {
 		panic(err)
 	}
+
+	server := &http.Server{
+		Addr:              c.serverIp + ":" + c.serverPort,
+		Handler:           r,
+		TLSConfig:         tlsConfig,
+		ReadHeaderTimeout: c.readHeaderTimeout,
+		ReadTimeout:       c.readTimeout,
+		WriteTimeout:      c.writeTimeout,
+		IdleTimeout:       c.idleTimeout,
+	}
+
+	go func() {
+		var err error
+		if c.tlsCert != "" && c.tlsKey != "" {
+			err = server.ListenAndServeTLS(c.tlsCert, c.tlsKey)
+		} else {
+			err = server.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
+			panic(err)
+		}
+	}()
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+
+	var ctx context.Context
+	var cancel context.CancelFunc
+	for {
+		sig := <-sigCh
+		if sig == syscall.SIGHUP {
+			if err := pipeline.Reload(); err != nil {
+				fmt.Fprintf(os.Stderr, "kong-http-log: pipeline reload failed, keeping previous config: %v\n", err)
+			}
+			continue
+		}
+		ctx, cancel = context.WithTimeout(context.Background(), c.shutdownTimeout)
+		defer cancel()
+		break
+	}
+
+	server.Shutdown(ctx)
+	metricsServer.Shutdown(ctx)
+
+	inFlight.Wait()
+	close(channel)
+	workers.Wait()
+
+	sinks.Close()
 }
 
-func handleLog(logChannel chan []byte, writer Handler,c *ConfigStruct) {
-	for json := range logChannel {
-		writer.Write(json)
+func handleLog(logChannel chan []byte, sinks *SinkManager, pipeline *kongjson.Manager, c *ConfigStruct, workers *sync.WaitGroup) {
+	defer workers.Done()
+	for raw := range logChannel {
+		out, keep, err := pipeline.Process(raw)
+		if err != nil {
+			// Unparseable payload: fall back to relaying it verbatim rather
+			// than silently dropping a record the pipeline can't read.
+			sinks.Dispatch(raw)
+			c.handledNum++
+			continue
+		}
+		if !keep {
+			continue
+		}
+		sinks.Dispatch(out)
 		c.handledNum++
 	}
 }
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	acceptedRequests = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "kong_log_accepted_requests_total",
+		Help: "Total number of /kong-log requests accepted.",
+	})
+	rejectedRequests = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "kong_log_rejected_requests_total",
+		Help: "Total number of /kong-log requests rejected because the ingest channel was full.",
+	})
+	unauthorizedRequests = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "kong_log_unauthorized_requests_total",
+		Help: "Total number of /kong-log requests rejected for a missing or invalid signature.",
+	})
+	bytesWritten = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "kong_log_bytes_written_total",
+		Help: "Total number of raw log bytes accepted onto the ingest channel, before sampling and filtering.",
+	})
+	rotationEvents = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "kong_log_rotation_events_total",
+		Help: "Total number of file handler rotations.",
+	})
+	sinkErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "kong_log_sink_errors_total",
+		Help: "Total number of sink write failures, by sink name.",
+	}, []string{"sink"})
+
+	requestBodySize = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "kong_log_request_body_bytes",
+		Help:    "Size in bytes of the request body posted to /kong-log.",
+		Buckets: prometheus.ExponentialBuckets(128, 4, 8),
+	})
+	handlerLatency = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "kong_log_handler_latency_seconds",
+		Help:    "Latency of the /kong-log HTTP handler.",
+		Buckets: prometheus.DefBuckets,
+	})
+)
+
+// channelSaturationThreshold is how full the ingest channel can get, as a
+// fraction of its capacity, before /readyz reports not-ready.
+const channelSaturationThreshold = 0.9
+
+// startMetricsServer serves /metrics, /healthz and /readyz on their own
+// listener, separate from the ingest port, so probes keep working even if
+// the ingest engine is saturated.
+func startMetricsServer(addr string, channel chan []byte, sinks *SinkManager) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("ok"))
+	})
+
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		if !sinks.Healthy() {
+			http.Error(w, "sinks unhealthy", http.StatusServiceUnavailable)
+			return
+		}
+		if float64(len(channel))/float64(cap(channel)) > channelSaturationThreshold {
+			http.Error(w, "channel saturated", http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("ok"))
+	})
+
+	srv := &http.Server{Addr: addr, Handler: mux}
+	go srv.ListenAndServe()
+	return srv
+}
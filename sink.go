@@ -0,0 +1,306 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Sink is anything that can durably accept a raw Kong log record. File,
+// Kafka, S3/GCS and Elasticsearch sinks below all implement it; handleLog
+// no longer knows which kind of sink it is talking to.
+type Sink interface {
+	Write(ctx context.Context, p []byte) error
+	Flush() error
+	Close() error
+}
+
+// backpressure selects what a sinkRunner does when its internal queue is
+// full.
+type backpressure int
+
+const (
+	BackpressureBlock backpressure = iota
+	BackpressureDropOldest
+	BackpressureDropNewest
+)
+
+func parseBackpressure(s string) backpressure {
+	switch strings.ToLower(s) {
+	case "drop-oldest":
+		return BackpressureDropOldest
+	case "drop-newest":
+		return BackpressureDropNewest
+	default:
+		return BackpressureBlock
+	}
+}
+
+// sinkStats holds the accepted/dropped/failed counters surfaced on
+// /kong-log-stat for a single sink.
+type sinkStats struct {
+	accepted uint64
+	dropped  uint64
+	failed   uint64
+}
+
+func (s *sinkStats) snapshot() map[string]uint64 {
+	return map[string]uint64{
+		"accepted": atomic.LoadUint64(&s.accepted),
+		"dropped":  atomic.LoadUint64(&s.dropped),
+		"failed":   atomic.LoadUint64(&s.failed),
+	}
+}
+
+// sinkRunner owns a bounded queue in front of a Sink and the goroutine that
+// drains it, so a slow downstream sink can never block the other sinks or
+// the HTTP handler beyond its own queue's capacity.
+type sinkRunner struct {
+	name    string
+	sink    Sink
+	queue   chan []byte
+	policy  backpressure
+	stats   sinkStats
+	wg      sync.WaitGroup
+	healthy int32 // 1 while the sink's last write succeeded, 0 otherwise
+}
+
+func newSinkRunner(name string, sink Sink, queueSize int, policy backpressure) *sinkRunner {
+	if queueSize <= 0 {
+		queueSize = 1
+	}
+	r := &sinkRunner{
+		name:    name,
+		sink:    sink,
+		queue:   make(chan []byte, queueSize),
+		policy:  policy,
+		healthy: 1,
+	}
+	r.wg.Add(1)
+	go r.run()
+	return r
+}
+
+func (r *sinkRunner) run() {
+	defer r.wg.Done()
+	ctx := context.Background()
+	for p := range r.queue {
+		if err := r.sink.Write(ctx, p); err != nil {
+			atomic.AddUint64(&r.stats.failed, 1)
+			atomic.StoreInt32(&r.healthy, 0)
+			sinkErrors.WithLabelValues(r.name).Inc()
+			continue
+		}
+		atomic.AddUint64(&r.stats.accepted, 1)
+		atomic.StoreInt32(&r.healthy, 1)
+	}
+}
+
+// enqueue applies the runner's backpressure policy and returns once p has
+// either been queued or dropped.
+func (r *sinkRunner) enqueue(p []byte) {
+	switch r.policy {
+	case BackpressureDropNewest:
+		select {
+		case r.queue <- p:
+		default:
+			atomic.AddUint64(&r.stats.dropped, 1)
+		}
+	case BackpressureDropOldest:
+		select {
+		case r.queue <- p:
+		default:
+			select {
+			case <-r.queue:
+				atomic.AddUint64(&r.stats.dropped, 1)
+			default:
+			}
+			select {
+			case r.queue <- p:
+			default:
+				atomic.AddUint64(&r.stats.dropped, 1)
+			}
+		}
+	default: // BackpressureBlock
+		r.queue <- p
+	}
+}
+
+// drain closes the queue and waits for the drain goroutine to finish, then
+// flushes and closes the underlying sink.
+func (r *sinkRunner) drain() {
+	close(r.queue)
+	r.wg.Wait()
+	r.sink.Flush()
+	r.sink.Close()
+}
+
+// SinkManager fans a single stream of records out to every configured sink.
+type SinkManager struct {
+	runners  []*sinkRunner
+	inFlight sync.WaitGroup
+}
+
+// Dispatch hands p to every configured sink concurrently, so a sink that is
+// stalled (full queue, BackpressureBlock) only ever blocks its own enqueue
+// goroutine rather than the caller and the other sinks behind it. Close
+// waits for inFlight before draining any runner's queue, so a still-running
+// enqueue can never send on a queue that has already been closed.
+func (m *SinkManager) Dispatch(p []byte) {
+	for _, r := range m.runners {
+		m.inFlight.Add(1)
+		go func(r *sinkRunner) {
+			defer m.inFlight.Done()
+			r.enqueue(p)
+		}(r)
+	}
+}
+
+func (m *SinkManager) Close() {
+	m.inFlight.Wait()
+	for _, r := range m.runners {
+		r.drain()
+	}
+}
+
+// Healthy reports whether every sink's last write succeeded.
+func (m *SinkManager) Healthy() bool {
+	for _, r := range m.runners {
+		if atomic.LoadInt32(&r.healthy) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (m *SinkManager) Stats() map[string]map[string]uint64 {
+	out := make(map[string]map[string]uint64, len(m.runners))
+	for _, r := range m.runners {
+		out[r.name] = r.stats.snapshot()
+	}
+	return out
+}
+
+// sinkConfigFile is the shape of the YAML file passed via -sinks-config.
+type sinkConfigFile struct {
+	Sinks []sinkEntry `yaml:"sinks"`
+}
+
+type sinkEntry struct {
+	Name         string `yaml:"name"`
+	Type         string `yaml:"type"`
+	QueueSize    int    `yaml:"queue_size"`
+	Backpressure string `yaml:"backpressure"`
+
+	// file sink
+	Path       string `yaml:"path"`
+	MaxSizeMB  int    `yaml:"max_size_mb"`
+	MaxBackups int    `yaml:"max_backups"`
+	MaxAgeDays int    `yaml:"max_age_days"`
+	Compress   bool   `yaml:"compress"`
+
+	// kafka sink
+	Brokers []string `yaml:"brokers"`
+	Topic   string   `yaml:"topic"`
+	Acks    string   `yaml:"acks"`
+
+	// s3/gcs batched-object sink
+	Bucket       string `yaml:"bucket"`
+	KeyPrefix    string `yaml:"key_prefix"`
+	BatchMaxMB   int    `yaml:"batch_max_mb"`
+	BatchMaxSecs int    `yaml:"batch_max_secs"`
+
+	// elasticsearch bulk sink
+	URL           string `yaml:"url"`
+	Index         string `yaml:"index"`
+	FlushInterval int    `yaml:"flush_interval_secs"`
+}
+
+// loadSinkConfig reads the YAML sink config named by path.
+func loadSinkConfig(path string) (*sinkConfigFile, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &sinkConfigFile{}
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// buildSinks constructs a SinkManager from either a YAML config file
+// (c.sinksConfig) or, failing that, the simple comma-separated -sinks flag,
+// which enables sinks using the rest of ConfigStruct's flags as defaults.
+func buildSinks(c *ConfigStruct) (*SinkManager, error) {
+	var entries []sinkEntry
+
+	if c.sinksConfig != "" {
+		cfg, err := loadSinkConfig(c.sinksConfig)
+		if err != nil {
+			return nil, fmt.Errorf("loading sinks config: %w", err)
+		}
+		entries = cfg.Sinks
+	} else if c.sinks != "" {
+		for _, t := range strings.Split(c.sinks, ",") {
+			t = strings.TrimSpace(t)
+			if t == "" {
+				continue
+			}
+			entries = append(entries, sinkEntry{
+				Name:       t,
+				Type:       t,
+				Path:       c.logPath,
+				MaxSizeMB:  c.maxSizeMB,
+				MaxBackups: c.maxBackups,
+				MaxAgeDays: c.maxAgeDays,
+				Compress:   c.compress,
+			})
+		}
+	} else {
+		entries = []sinkEntry{{Name: "file", Type: "file", Path: c.logPath, MaxSizeMB: c.maxSizeMB, MaxBackups: c.maxBackups, MaxAgeDays: c.maxAgeDays, Compress: c.compress}}
+	}
+
+	m := &SinkManager{}
+	for _, e := range entries {
+		sink, err := newSinkFromEntry(e)
+		if err != nil {
+			return nil, fmt.Errorf("sink %q: %w", e.Name, err)
+		}
+		queueSize := e.QueueSize
+		if queueSize == 0 {
+			queueSize = 256
+		}
+		name := e.Name
+		if name == "" {
+			name = e.Type
+		}
+		m.runners = append(m.runners, newSinkRunner(name, sink, queueSize, parseBackpressure(e.Backpressure)))
+	}
+
+	return m, nil
+}
+
+func newSinkFromEntry(e sinkEntry) (Sink, error) {
+	switch strings.ToLower(e.Type) {
+	case "file", "":
+		handler, err := newLogHandler(e.Path, WhenHour, 3600, e.MaxSizeMB, e.MaxBackups, e.MaxAgeDays, e.Compress)
+		if err != nil {
+			return nil, err
+		}
+		return &fileSink{handler: handler}, nil
+	case "kafka":
+		return newKafkaSink(e.Brokers, e.Topic, e.Acks)
+	case "s3", "gcs":
+		return newObjectStoreSink(e.Bucket, e.KeyPrefix, e.BatchMaxMB, e.BatchMaxSecs)
+	case "elasticsearch", "es":
+		return newElasticsearchSink(e.URL, e.Index, e.FlushInterval)
+	default:
+		return nil, fmt.Errorf("unknown sink type %q", e.Type)
+	}
+}
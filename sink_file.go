@@ -0,0 +1,22 @@
+package main
+
+import "context"
+
+// fileSink adapts the existing RotatingFileHandler to the Sink interface so
+// it keeps working as one sink among many.
+type fileSink struct {
+	handler *RotatingFileHandler
+}
+
+func (s *fileSink) Write(ctx context.Context, p []byte) error {
+	_, err := s.handler.Write(p)
+	return err
+}
+
+func (s *fileSink) Flush() error {
+	return nil
+}
+
+func (s *fileSink) Close() error {
+	return s.handler.Close()
+}
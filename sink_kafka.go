@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// kafkaSink publishes each record to a Kafka topic via a single shared
+// writer. kafka-go batches and retries internally, so the sink itself stays
+// a thin wrapper.
+type kafkaSink struct {
+	writer *kafka.Writer
+}
+
+func newKafkaSink(brokers []string, topic, acks string) (*kafkaSink, error) {
+	if topic == "" {
+		return nil, fmt.Errorf("kafka sink requires a topic")
+	}
+	if len(brokers) == 0 {
+		return nil, fmt.Errorf("kafka sink requires at least one broker")
+	}
+
+	w := &kafka.Writer{
+		Addr:         kafka.TCP(brokers...),
+		Topic:        topic,
+		Balancer:     &kafka.LeastBytes{},
+		RequiredAcks: parseKafkaAcks(acks),
+	}
+
+	return &kafkaSink{writer: w}, nil
+}
+
+func parseKafkaAcks(acks string) kafka.RequiredAcks {
+	switch acks {
+	case "0", "none":
+		return kafka.RequireNone
+	case "1", "leader":
+		return kafka.RequireOne
+	case "all", "-1":
+		return kafka.RequireAll
+	default:
+		return kafka.RequireOne
+	}
+}
+
+func (s *kafkaSink) Write(ctx context.Context, p []byte) error {
+	return s.writer.WriteMessages(ctx, kafka.Message{Value: p})
+}
+
+func (s *kafkaSink) Flush() error {
+	return nil
+}
+
+func (s *kafkaSink) Close() error {
+	return s.writer.Close()
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// objectStoreSink buffers incoming records and flushes them as a single
+// gzip'd NDJSON object once either batchMaxBytes or batchMaxAge is reached,
+// whichever comes first. Objects are keyed by a time-prefixed name so a
+// bucket listing sorts chronologically.
+type objectStoreSink struct {
+	bucket    string
+	keyPrefix string
+
+	batchMaxBytes int
+	batchMaxAge   time.Duration
+
+	client *s3.S3
+
+	mux        sync.Mutex
+	buf        bytes.Buffer
+	bufOpenAt  time.Time
+	flushTimer *time.Timer
+}
+
+func newObjectStoreSink(bucket, keyPrefix string, batchMaxMB, batchMaxSecs int) (*objectStoreSink, error) {
+	if bucket == "" {
+		return nil, fmt.Errorf("object store sink requires a bucket")
+	}
+	if batchMaxMB <= 0 {
+		batchMaxMB = 8
+	}
+	if batchMaxSecs <= 0 {
+		batchMaxSecs = 30
+	}
+
+	sess, err := session.NewSession()
+	if err != nil {
+		return nil, err
+	}
+
+	s := &objectStoreSink{
+		bucket:        bucket,
+		keyPrefix:     keyPrefix,
+		batchMaxBytes: batchMaxMB * 1024 * 1024,
+		batchMaxAge:   time.Duration(batchMaxSecs) * time.Second,
+		client:        s3.New(sess),
+	}
+	s.bufOpenAt = time.Now()
+	s.flushTimer = time.AfterFunc(s.batchMaxAge, func() { s.Flush() })
+
+	return s, nil
+}
+
+func (s *objectStoreSink) Write(ctx context.Context, p []byte) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.buf.Write(p)
+	s.buf.WriteByte('\n')
+
+	if s.buf.Len() >= s.batchMaxBytes {
+		return s.flushLocked(ctx)
+	}
+	return nil
+}
+
+func (s *objectStoreSink) Flush() error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return s.flushLocked(context.Background())
+}
+
+// flushLocked gzips the buffered NDJSON and PUTs it under a key of the form
+// <prefix><year>/<month>/<day>/<hh>-<mm>-<ss>.<nanoseconds>.ndjson.gz. Caller holds s.mux. The
+// timer is rearmed unconditionally, including on error: it only ever fires
+// once per Reset, so a transient gzip or PutObject failure must not be
+// allowed to permanently disable time-based flushing.
+func (s *objectStoreSink) flushLocked(ctx context.Context) error {
+	defer s.resetTimerLocked()
+
+	if s.buf.Len() == 0 {
+		return nil
+	}
+
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	if _, err := w.Write(s.buf.Bytes()); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	key := fmt.Sprintf("%s%s.ndjson.gz", s.keyPrefix, time.Now().Format("2006/01/02/15-04-05.000000000"))
+
+	_, err := s.client.PutObjectWithContext(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+		Body:   bytes.NewReader(gz.Bytes()),
+	})
+	if err != nil {
+		return err
+	}
+
+	s.buf.Reset()
+	s.bufOpenAt = time.Now()
+	return nil
+}
+
+func (s *objectStoreSink) resetTimerLocked() {
+	s.flushTimer.Reset(s.batchMaxAge)
+}
+
+func (s *objectStoreSink) Close() error {
+	s.flushTimer.Stop()
+	return s.Flush()
+}